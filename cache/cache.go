@@ -0,0 +1,177 @@
+// Package cache stores fetched mail.Info records locally, keyed by
+// (mailbox, UID) and tied to the mailbox's UIDVALIDITY, so that repeated
+// runs against large mailboxes only need to fetch messages appended since
+// the last run. Only one UIDVALIDITY is kept per mailbox.
+package cache
+
+import (
+	"encoding/json"
+	"sort"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+
+	"github.com/Kuzuru/IMAP/mail"
+)
+
+// schemaVersion is bumped whenever the on-disk record layout changes, so
+// a future Open can detect and migrate an older cache file.
+const schemaVersion = 1
+
+var (
+	metaBucket     = []byte("meta")
+	messagesPrefix = "messages/"
+)
+
+// Store is a local, on-disk cache of fetched mail.Info records.
+type Store struct {
+	db *bolt.DB
+}
+
+// Open opens (creating if necessary) a cache database at path.
+func Open(path string) (*Store, error) {
+	db, err := bolt.Open(path, 0o600, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, err
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		_, err := tx.CreateBucketIfNotExists(metaBucket)
+		return err
+	}); err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	s := &Store{db: db}
+	if err := s.migrate(); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+// Close releases the underlying database file.
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// migrate brings an older cache file up to schemaVersion. There is only
+// one version today, so this just stamps a fresh cache.
+func (s *Store) migrate() error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b := tx.Bucket(metaBucket)
+		if b.Get([]byte("schema_version")) != nil {
+			return nil
+		}
+		return b.Put([]byte("schema_version"), []byte{schemaVersion})
+	})
+}
+
+// mailboxState is the sync checkpoint for one mailbox.
+type mailboxState struct {
+	UidValidity uint32 `json:"uid_validity"`
+	LastSeenUid uint32 `json:"last_seen_uid"`
+}
+
+func stateKey(mailbox string) []byte {
+	return []byte("mailbox/" + mailbox)
+}
+
+func messagesBucketName(mailbox string) []byte {
+	return []byte(messagesPrefix + mailbox)
+}
+
+// State returns the stored UIDVALIDITY and highest cached UID for
+// mailbox. Both are zero if the mailbox has never been synced.
+func (s *Store) State(mailbox string) (uidValidity, lastSeenUid uint32, err error) {
+	err = s.db.View(func(tx *bolt.Tx) error {
+		data := tx.Bucket(metaBucket).Get(stateKey(mailbox))
+		if data == nil {
+			return nil
+		}
+		var st mailboxState
+		if err := json.Unmarshal(data, &st); err != nil {
+			return err
+		}
+		uidValidity, lastSeenUid = st.UidValidity, st.LastSeenUid
+		return nil
+	})
+	return uidValidity, lastSeenUid, err
+}
+
+// Reset discards every cached message for mailbox. Callers use this when
+// the server's UIDVALIDITY has changed, meaning previously cached UIDs no
+// longer identify the same messages.
+func (s *Store) Reset(mailbox string) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		if err := tx.DeleteBucket(messagesBucketName(mailbox)); err != nil && err != bolt.ErrBucketNotFound {
+			return err
+		}
+		return tx.Bucket(metaBucket).Delete(stateKey(mailbox))
+	})
+}
+
+// Put merges fresh into the cache for mailbox and advances the stored
+// UIDVALIDITY/last-seen-UID checkpoint.
+func (s *Store) Put(mailbox string, uidValidity uint32, fresh []mail.Info) error {
+	return s.db.Update(func(tx *bolt.Tx) error {
+		b, err := tx.CreateBucketIfNotExists(messagesBucketName(mailbox))
+		if err != nil {
+			return err
+		}
+
+		_, lastSeenUid, err := s.State(mailbox)
+		if err != nil {
+			return err
+		}
+
+		for _, info := range fresh {
+			data, err := json.Marshal(info)
+			if err != nil {
+				return err
+			}
+			if err := b.Put(uidKey(info.UID), data); err != nil {
+				return err
+			}
+			if info.UID > lastSeenUid {
+				lastSeenUid = info.UID
+			}
+		}
+
+		data, err := json.Marshal(mailboxState{UidValidity: uidValidity, LastSeenUid: lastSeenUid})
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(metaBucket).Put(stateKey(mailbox), data)
+	})
+}
+
+// All returns every cached mail.Info for mailbox, ordered by UID.
+func (s *Store) All(mailbox string) ([]mail.Info, error) {
+	var mails []mail.Info
+	err := s.db.View(func(tx *bolt.Tx) error {
+		b := tx.Bucket(messagesBucketName(mailbox))
+		if b == nil {
+			return nil
+		}
+		return b.ForEach(func(_, data []byte) error {
+			var m mail.Info
+			if err := json.Unmarshal(data, &m); err != nil {
+				return err
+			}
+			mails = append(mails, m)
+			return nil
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	sort.Slice(mails, func(i, j int) bool { return mails[i].UID < mails[j].UID })
+	return mails, nil
+}
+
+func uidKey(uid uint32) []byte {
+	return []byte{byte(uid >> 24), byte(uid >> 16), byte(uid >> 8), byte(uid)}
+}
@@ -0,0 +1,58 @@
+package cache
+
+import (
+	"github.com/emersion/go-imap"
+
+	"github.com/Kuzuru/IMAP/mail"
+)
+
+// Source is the subset of imapsvc.MailStore that Sync needs: select a
+// mailbox and fetch messages by UID. A real *imapsvc.Client satisfies
+// this, as does a fake in tests.
+type Source interface {
+	Select(mailbox string) (*imap.MailboxStatus, error)
+	Fetch(uidSet *imap.SeqSet, items []imap.FetchItem) ([]mail.Info, error)
+}
+
+// Sync selects mailbox on src, fetches only the messages appended since
+// the cache was last updated (using UIDVALIDITY to detect a mailbox that
+// was reset server-side), merges them into the cache, and returns every
+// cached message.
+//
+// This only scans for new UIDs, so it does not notice flag changes (e.g.
+// \Seen, \Deleted) on already-cached messages. Detecting those without a
+// full re-fetch needs CONDSTORE/HIGHESTMODSEQ (RFC 7162), which isn't
+// implemented here.
+func Sync(src Source, store *Store, mailbox string, items []imap.FetchItem) (*imap.MailboxStatus, []mail.Info, error) {
+	mbox, err := src.Select(mailbox)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	uidValidity, lastSeenUid, err := store.State(mailbox)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if uidValidity != 0 && uidValidity != mbox.UidValidity {
+		if err := store.Reset(mailbox); err != nil {
+			return nil, nil, err
+		}
+		lastSeenUid = 0
+	}
+
+	uidSet := new(imap.SeqSet)
+	uidSet.AddRange(lastSeenUid+1, 0)
+
+	fresh, err := src.Fetch(uidSet, items)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	if err := store.Put(mailbox, mbox.UidValidity, fresh); err != nil {
+		return nil, nil, err
+	}
+
+	mails, err := store.All(mailbox)
+	return mbox, mails, err
+}
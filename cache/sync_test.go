@@ -0,0 +1,110 @@
+package cache
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/emersion/go-imap"
+
+	"github.com/Kuzuru/IMAP/mail"
+)
+
+// fakeSource is a Source backed by an in-memory mailbox, for testing Sync
+// without a live server.
+type fakeSource struct {
+	uidValidity uint32
+	mails       []mail.Info // all mails that exist server-side, ordered by UID
+	fetchCalls  []*imap.SeqSet
+}
+
+func (f *fakeSource) Select(mailbox string) (*imap.MailboxStatus, error) {
+	return &imap.MailboxStatus{UidValidity: f.uidValidity}, nil
+}
+
+func (f *fakeSource) Fetch(uidSet *imap.SeqSet, items []imap.FetchItem) ([]mail.Info, error) {
+	f.fetchCalls = append(f.fetchCalls, uidSet)
+
+	var out []mail.Info
+	for _, m := range f.mails {
+		if uidSet.Contains(m.UID) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func TestSyncFetchesOnlyNewMessages(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+
+	src := &fakeSource{
+		uidValidity: 1,
+		mails: []mail.Info{
+			{UID: 1, Subject: "first"},
+			{UID: 2, Subject: "second"},
+		},
+	}
+
+	_, mails, err := Sync(src, store, "INBOX", nil)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(mails) != 2 {
+		t.Fatalf("got %d mails, want 2", len(mails))
+	}
+
+	src.mails = append(src.mails, mail.Info{UID: 3, Subject: "third"})
+
+	_, mails, err = Sync(src, store, "INBOX", nil)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(mails) != 3 {
+		t.Fatalf("got %d mails after second sync, want 3", len(mails))
+	}
+	if mails[2].Subject != "third" {
+		t.Fatalf("got subject %q, want %q", mails[2].Subject, "third")
+	}
+
+	if len(src.fetchCalls) != 2 {
+		t.Fatalf("got %d Fetch calls, want 2", len(src.fetchCalls))
+	}
+	if src.fetchCalls[1].Contains(1) || src.fetchCalls[1].Contains(2) {
+		t.Fatalf("second sync refetched already-cached UIDs: %v", src.fetchCalls[1])
+	}
+	if !src.fetchCalls[1].Contains(3) {
+		t.Fatalf("second sync did not request the new UID: %v", src.fetchCalls[1])
+	}
+}
+
+func TestSyncResetsOnUidValidityChange(t *testing.T) {
+	store, err := Open(filepath.Join(t.TempDir(), "cache.db"))
+	if err != nil {
+		t.Fatalf("Open: %v", err)
+	}
+	defer store.Close()
+
+	src := &fakeSource{
+		uidValidity: 1,
+		mails:       []mail.Info{{UID: 1, Subject: "first"}},
+	}
+	if _, _, err := Sync(src, store, "INBOX", nil); err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+
+	// Mailbox got recreated server-side: UIDVALIDITY changes and UIDs are
+	// renumbered from 1.
+	src.uidValidity = 2
+	src.mails = []mail.Info{{UID: 1, Subject: "new first"}}
+
+	_, mails, err := Sync(src, store, "INBOX", nil)
+	if err != nil {
+		t.Fatalf("Sync: %v", err)
+	}
+	if len(mails) != 1 || mails[0].Subject != "new first" {
+		t.Fatalf("got %+v, want a single cached mail for the new first message", mails)
+	}
+}
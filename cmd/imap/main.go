@@ -0,0 +1,468 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"os/exec"
+	"os/signal"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/emersion/go-imap"
+	"github.com/pkg/term"
+
+	"github.com/Kuzuru/IMAP/cache"
+	"github.com/Kuzuru/IMAP/download"
+	"github.com/Kuzuru/IMAP/imapsvc"
+	"github.com/Kuzuru/IMAP/mail"
+	"github.com/Kuzuru/IMAP/render"
+	"github.com/Kuzuru/IMAP/search"
+)
+
+// ./imap -s imap.mail.ru:993 -u <email> --ssl
+// ./imap download -s imap.mail.ru:993 -u <email> --ssl --dir ./attachments
+func main() {
+	if len(os.Args) > 1 && os.Args[1] == "download" {
+		runDownload(os.Args[2:])
+		return
+	}
+	runFetch(os.Args[1:])
+}
+
+func runFetch(args []string) {
+	fs := flag.NewFlagSet("imap", flag.ExitOnError)
+	help := fs.Bool("h", false, "help")
+	server := fs.String("s", "", "address (or domain name) of IMAP server in address[:port] format (default port is 143).")
+	user := fs.String("u", "", "username, ask for password after launching and don't show it on the screen.")
+	watch := fs.Bool("watch", false, "after the initial fetch, watch the mailbox via IMAP IDLE and print new messages as they arrive.")
+	cacheDir := fs.String("cache", "", "directory for a local cache keyed by UID and reset when UIDVALIDITY changes; when set, only messages appended since the last run are fetched, overriding -n and --search.")
+	cf := registerConnFlags(fs)
+	qf := registerQueryFlags(fs)
+	of := registerOutputFlags(fs)
+	fs.Parse(args)
+
+	if *help || *server == "" || *user == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	serverHost, serverPort := parseServer(*server)
+
+	fmt.Println("Parsed server: ", serverHost, serverPort)
+
+	password := getPassword()
+
+	fmt.Println("Got your password, connecting...")
+
+	opts, err := cf.resolve(*user, password)
+	if err != nil {
+		log.Fatalf("Failed to resolve auth options: %v", err)
+	}
+
+	store := imapsvc.New()
+	if err := store.Connect(serverHost, serverPort, opts); err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+
+	fmt.Println("Connected to server")
+
+	defer func() {
+		if err := store.Disconnect(); err != nil {
+			log.Printf("Failed to logout: %v", err)
+		}
+	}()
+
+	if *qf.listMailboxes {
+		printMailboxes(store)
+		return
+	}
+
+	fmt.Println("Fetching mails...")
+
+	writer, err := of.writer()
+	if err != nil {
+		log.Fatalf("Failed to set up output: %v", err)
+	}
+
+	var mbox *imap.MailboxStatus
+	var mails []mail.Info
+
+	if *cacheDir != "" {
+		mbox, mails, err = fetchWithCache(store, *cacheDir, *qf.mailbox)
+	} else {
+		if mbox, err = store.Select(*qf.mailbox); err == nil {
+			var uidSet *imap.SeqSet
+			if uidSet, err = qf.uidSet(store); err == nil {
+				mails, err = store.Fetch(uidSet, imapsvc.DefaultFetchItems)
+			}
+		}
+	}
+	if err != nil {
+		log.Fatalf("Failed to fetch mails: %v", err)
+	}
+
+	if err := writer.Write(mails); err != nil {
+		log.Fatalf("Failed to write output: %v", err)
+	}
+
+	if *watch {
+		watchMailbox(store, writer, *qf.mailbox, mbox.UidNext)
+	}
+}
+
+// fetchWithCache opens (creating if necessary) a per-mailbox cache
+// database under dir and syncs mailbox through it, so only messages
+// appended since the last run are fetched from the server.
+func fetchWithCache(store imapsvc.MailStore, dir, mailbox string) (*imap.MailboxStatus, []mail.Info, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, nil, err
+	}
+
+	c, err := cache.Open(filepath.Join(dir, cacheFileName(mailbox)))
+	if err != nil {
+		return nil, nil, err
+	}
+	defer c.Close()
+
+	return cache.Sync(store, c, mailbox, imapsvc.DefaultFetchItems)
+}
+
+// cacheFileName turns a mailbox name into a safe cache file name.
+func cacheFileName(mailbox string) string {
+	return strings.NewReplacer("/", "_", "\\", "_").Replace(mailbox) + ".db"
+}
+
+// watchMailbox enters an IMAP IDLE loop on mailbox and prints any messages
+// appended after nextUID, until interrupted (Ctrl+C).
+func watchMailbox(store imapsvc.MailStore, writer render.Writer, mailbox string, nextUID uint32) {
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		fmt.Println("Stopping watch...")
+		cancel()
+	}()
+
+	fmt.Println("Watching for new mail, press Ctrl+C to stop...")
+
+	err := store.Watch(ctx, func() {
+		mbox, err := store.Select(mailbox)
+		if err != nil {
+			log.Printf("watch: failed to re-select %s: %v", mailbox, err)
+			return
+		}
+
+		uidSet := new(imap.SeqSet)
+		uidSet.AddRange(nextUID, 0)
+
+		mails, err := store.Fetch(uidSet, imapsvc.DefaultFetchItems)
+		if err != nil {
+			log.Printf("watch: failed to fetch new mail: %v", err)
+			return
+		}
+		// "nextUID:*" also matches the highest existing UID when nothing
+		// newer exists, so keep only messages at or above nextUID.
+		var fresh []mail.Info
+		for _, m := range mails {
+			if m.UID >= nextUID {
+				fresh = append(fresh, m)
+			}
+		}
+		if len(fresh) > 0 {
+			if err := writer.Write(fresh); err != nil {
+				log.Printf("watch: failed to write output: %v", err)
+			}
+		}
+		nextUID = mbox.UidNext
+	})
+	if err != nil && ctx.Err() == nil {
+		log.Printf("watch stopped: %v", err)
+	}
+}
+
+// runDownload fetches the full body of each selected message and writes
+// its attachments to a subdirectory of dir named after the message's UID.
+func runDownload(args []string) {
+	fs := flag.NewFlagSet("imap download", flag.ExitOnError)
+	help := fs.Bool("h", false, "help")
+	server := fs.String("s", "", "address (or domain name) of IMAP server in address[:port] format (default port is 143).")
+	user := fs.String("u", "", "username, ask for password after launching and don't show it on the screen.")
+	dir := fs.String("dir", ".", "directory to write downloaded attachments to.")
+	cf := registerConnFlags(fs)
+	qf := registerQueryFlags(fs)
+	fs.Parse(args)
+
+	if *help || *server == "" || *user == "" {
+		fs.Usage()
+		os.Exit(1)
+	}
+
+	serverHost, serverPort := parseServer(*server)
+
+	fmt.Println("Parsed server: ", serverHost, serverPort)
+
+	password := getPassword()
+
+	fmt.Println("Got your password, connecting...")
+
+	opts, err := cf.resolve(*user, password)
+	if err != nil {
+		log.Fatalf("Failed to resolve auth options: %v", err)
+	}
+
+	store := imapsvc.New()
+	if err := store.Connect(serverHost, serverPort, opts); err != nil {
+		log.Fatalf("Failed to connect: %v", err)
+	}
+
+	fmt.Println("Connected to server")
+
+	defer func() {
+		if err := store.Disconnect(); err != nil {
+			log.Printf("Failed to logout: %v", err)
+		}
+	}()
+
+	if *qf.listMailboxes {
+		printMailboxes(store)
+		return
+	}
+
+	if _, err := store.Select(*qf.mailbox); err != nil {
+		log.Fatalf("Failed to select %s: %v", *qf.mailbox, err)
+	}
+
+	uidSet, err := qf.uidSet(store)
+	if err != nil {
+		log.Fatalf("Failed to resolve messages to fetch: %v", err)
+	}
+
+	bodies, err := store.FetchBody(uidSet)
+	if err != nil {
+		log.Fatalf("Failed to fetch bodies: %v", err)
+	}
+
+	for uid, body := range bodies {
+		msg, err := download.Parse(body)
+		if err != nil {
+			log.Printf("message %d: failed to parse: %v", uid, err)
+			continue
+		}
+
+		msgDir := filepath.Join(*dir, strconv.Itoa(int(uid)))
+		written, err := download.Save(msg, msgDir)
+		if err != nil {
+			log.Printf("message %d: failed to save attachments: %v", uid, err)
+			continue
+		}
+
+		for _, path := range written {
+			fmt.Println(path)
+		}
+	}
+}
+
+func printMailboxes(store imapsvc.MailStore) {
+	folders, err := store.Folders()
+	if err != nil {
+		log.Fatalf("Failed to list mailboxes: %v", err)
+	}
+	for _, name := range folders {
+		fmt.Println(name)
+	}
+}
+
+// connFlags holds the connection/authentication flags shared by every
+// subcommand.
+type connFlags struct {
+	ssl                *bool
+	startTLS           *bool
+	insecureSkipVerify *bool
+	auth               *string
+	tokenCmd           *string
+}
+
+func registerConnFlags(fs *flag.FlagSet) *connFlags {
+	return &connFlags{
+		ssl:                fs.Bool("ssl", false, "allow ssl if server supports it (by default do not use it)."),
+		startTLS:           fs.Bool("starttls", false, "dial in cleartext and upgrade with STARTTLS before authenticating."),
+		insecureSkipVerify: fs.Bool("insecure-skip-verify", false, "skip TLS certificate verification (for self-signed servers)."),
+		auth:               fs.String("auth", string(imapsvc.AuthPlain), "authentication mechanism: plain, login or xoauth2."),
+		tokenCmd:           fs.String("token-cmd", "", "command to run to obtain an XOAUTH2 bearer token (required with --auth xoauth2)."),
+	}
+}
+
+// resolve turns the parsed flags into imapsvc.ConnectOptions, running
+// token-cmd when xoauth2 authentication was requested.
+func (cf *connFlags) resolve(user, password string) (imapsvc.ConnectOptions, error) {
+	opts := imapsvc.ConnectOptions{
+		SSL:                *cf.ssl,
+		StartTLS:           *cf.startTLS,
+		InsecureSkipVerify: *cf.insecureSkipVerify,
+		Auth:               imapsvc.AuthMethod(*cf.auth),
+		User:               user,
+		Password:           password,
+	}
+
+	if opts.Auth == imapsvc.AuthXOAuth2 {
+		if *cf.tokenCmd == "" {
+			return opts, fmt.Errorf("--auth xoauth2 requires --token-cmd")
+		}
+		token, err := runTokenCmd(*cf.tokenCmd)
+		if err != nil {
+			return opts, fmt.Errorf("running --token-cmd: %w", err)
+		}
+		opts.Token = token
+	}
+
+	return opts, nil
+}
+
+// runTokenCmd executes the given command line and returns its trimmed
+// stdout as the bearer token.
+func runTokenCmd(cmdline string) (string, error) {
+	parts := strings.Fields(cmdline)
+	if len(parts) == 0 {
+		return "", fmt.Errorf("empty --token-cmd")
+	}
+
+	out, err := exec.Command(parts[0], parts[1:]...).Output()
+	if err != nil {
+		return "", err
+	}
+	return strings.TrimSpace(string(out)), nil
+}
+
+func parseServer(server string) (string, string) {
+	host, port, err := net.SplitHostPort(server)
+	if err != nil {
+		return server, "143"
+	}
+	return host, port
+}
+
+func getPassword() string {
+	fmt.Print("Enter your password: ")
+	password, _ := readPassword()
+	fmt.Println()
+	return string(password)
+}
+
+// queryFlags holds the flags that decide which mailbox and which messages
+// within it a subcommand operates on.
+type queryFlags struct {
+	mailbox       *string
+	listMailboxes *bool
+	numRange      *string
+	searchExpr    *string
+}
+
+func registerQueryFlags(fs *flag.FlagSet) *queryFlags {
+	return &queryFlags{
+		mailbox:       fs.String("mailbox", "INBOX", "mailbox to select."),
+		listMailboxes: fs.Bool("list-mailboxes", false, "list mailboxes and exit."),
+		numRange:      fs.String("n", "", "range of mails (UIDs), all by default."),
+		searchExpr:    fs.String("search", "", `search expression, e.g. from:alice@example.com since:2024-01-01 subject:"invoice" unseen larger:1M`),
+	}
+}
+
+// uidSet resolves --search (if given) or -n (all messages by default) to
+// the UID set that Fetch/FetchBody should operate on. The mailbox must
+// already be selected.
+func (qf *queryFlags) uidSet(store imapsvc.MailStore) (*imap.SeqSet, error) {
+	if *qf.searchExpr != "" {
+		criteria, err := search.Parse(*qf.searchExpr)
+		if err != nil {
+			return nil, fmt.Errorf("parsing --search: %w", err)
+		}
+		uids, err := store.Search(criteria)
+		if err != nil {
+			return nil, err
+		}
+		uidSet := new(imap.SeqSet)
+		for _, uid := range uids {
+			uidSet.AddNum(uid)
+		}
+		return uidSet, nil
+	}
+
+	if *qf.numRange == "" {
+		uidSet := new(imap.SeqSet)
+		uidSet.AddRange(1, 0) // "1:*" — every UID in the mailbox
+		return uidSet, nil
+	}
+	return imap.ParseSeqSet(*qf.numRange)
+}
+
+// outputFlags selects the rendering format and columns for fetched mail.
+type outputFlags struct {
+	format *string
+	fields *string
+}
+
+func registerOutputFlags(fs *flag.FlagSet) *outputFlags {
+	return &outputFlags{
+		format: fs.String("format", string(render.FormatText), "output format: text, json, ndjson or csv."),
+		fields: fs.String("fields", "", "comma-separated columns to output, e.g. from,to,subject,date,size,attachments,attachment_names,message_id,in_reply_to,uid,flags (default: from,to,subject,date,size,attachments,attachment_names)."),
+	}
+}
+
+func (of *outputFlags) writer() (render.Writer, error) {
+	var fields []string
+	if *of.fields != "" {
+		fields = strings.Split(*of.fields, ",")
+	}
+	return render.NewWriter(os.Stdout, render.Format(*of.format), fields)
+}
+
+func readPassword() ([]byte, error) {
+	t, err := term.Open("/dev/tty")
+	if err != nil {
+		return nil, err
+	}
+
+	err = term.RawMode(t)
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	buf := new(bytes.Buffer)
+	reader := bufio.NewReader(t)
+
+	for {
+		ch, _, err := reader.ReadRune()
+		if err != nil {
+			t.Restore()
+			t.Close()
+			return nil, err
+		}
+		if ch == '\r' || ch == '\n' {
+			break
+		}
+		buf.WriteRune(ch)
+	}
+
+	err = t.Restore()
+	if err != nil {
+		t.Close()
+		return nil, err
+	}
+
+	err = t.Close()
+	if err != nil {
+		return nil, err
+	}
+
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,111 @@
+// Package download parses full RFC 822 messages fetched via IMAP BODY[]
+// into decoded text parts and attachments, and writes attachments to disk.
+package download
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	// Registers non-UTF-8 charsets (koi8-r, gbk, ...) with the mime
+	// package so Subject and filename decoding works for Cyrillic/CJK mail.
+	_ "github.com/emersion/go-message/charset"
+	emmail "github.com/emersion/go-message/mail"
+)
+
+// Message is a fully parsed message body.
+type Message struct {
+	Text        string
+	HTML        string
+	Attachments []Attachment
+}
+
+// Attachment is a decoded attachment, not yet written to disk.
+type Attachment struct {
+	Filename string
+	Bytes    []byte
+}
+
+// Parse reads a full message (as returned by an IMAP BODY[] fetch) and
+// splits it into text, HTML and attachment parts.
+func Parse(r io.Reader) (*Message, error) {
+	mr, err := emmail.CreateReader(r)
+	if err != nil {
+		return nil, err
+	}
+
+	msg := &Message{}
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		switch h := part.Header.(type) {
+		case *emmail.InlineHeader:
+			contentType, _, _ := h.ContentType()
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				return nil, err
+			}
+			switch contentType {
+			case "text/html":
+				msg.HTML += string(body)
+			default:
+				msg.Text += string(body)
+			}
+		case *emmail.AttachmentHeader:
+			filename, err := h.Filename()
+			if err != nil || filename == "" {
+				filename = "attachment"
+			}
+			body, err := io.ReadAll(part.Body)
+			if err != nil {
+				return nil, err
+			}
+			msg.Attachments = append(msg.Attachments, Attachment{
+				Filename: sanitizeFilename(filename),
+				Bytes:    body,
+			})
+		}
+	}
+
+	return msg, nil
+}
+
+// Save writes every attachment in msg to dir, creating it if necessary,
+// and returns the paths written. Callers fetching more than one message
+// should give each its own dir (e.g. namespaced by UID): two unrelated
+// messages commonly share an attachment filename, and Save does not
+// detect or rename on collision.
+func Save(msg *Message, dir string) ([]string, error) {
+	if err := os.MkdirAll(dir, 0o755); err != nil {
+		return nil, err
+	}
+
+	var written []string
+	for _, att := range msg.Attachments {
+		path := filepath.Join(dir, att.Filename)
+		if err := os.WriteFile(path, att.Bytes, 0o644); err != nil {
+			return written, fmt.Errorf("writing %s: %w", att.Filename, err)
+		}
+		written = append(written, path)
+	}
+	return written, nil
+}
+
+// sanitizeFilename strips directory components and RFC 2231/path
+// traversal tricks from a MIME filename so it is safe to join under a
+// destination directory.
+func sanitizeFilename(name string) string {
+	name = filepath.Base(filepath.FromSlash(strings.ReplaceAll(name, "\\", "/")))
+	if name == "" || name == "." || name == ".." {
+		name = "attachment"
+	}
+	return name
+}
@@ -0,0 +1,88 @@
+package download
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSanitizeFilename(t *testing.T) {
+	cases := map[string]string{
+		"invoice.pdf":          "invoice.pdf",
+		"../../etc/passwd":     "passwd",
+		"..\\..\\windows\\win": "win",
+		"/etc/passwd":          "passwd",
+		"":                     "attachment",
+		".":                    "attachment",
+		"..":                   "attachment",
+	}
+	for in, want := range cases {
+		if got := sanitizeFilename(in); got != want {
+			t.Errorf("sanitizeFilename(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+func TestSaveWritesAttachments(t *testing.T) {
+	msg := &Message{
+		Attachments: []Attachment{
+			{Filename: "a.txt", Bytes: []byte("hello")},
+			{Filename: "b.txt", Bytes: []byte("world")},
+		},
+	}
+
+	dir := filepath.Join(t.TempDir(), "1")
+	written, err := Save(msg, dir)
+	if err != nil {
+		t.Fatalf("Save: %v", err)
+	}
+	if len(written) != 2 {
+		t.Fatalf("got %d paths written, want 2", len(written))
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "a.txt"))
+	if err != nil {
+		t.Fatalf("ReadFile: %v", err)
+	}
+	if string(data) != "hello" {
+		t.Fatalf("got %q, want %q", data, "hello")
+	}
+}
+
+func TestParseTextAndAttachment(t *testing.T) {
+	raw := "From: a@example.com\r\n" +
+		"To: b@example.com\r\n" +
+		"Subject: test\r\n" +
+		"MIME-Version: 1.0\r\n" +
+		"Content-Type: multipart/mixed; boundary=b1\r\n" +
+		"\r\n" +
+		"--b1\r\n" +
+		"Content-Type: text/plain\r\n" +
+		"\r\n" +
+		"hello there\r\n" +
+		"--b1\r\n" +
+		"Content-Type: application/octet-stream\r\n" +
+		"Content-Disposition: attachment; filename=\"note.txt\"\r\n" +
+		"\r\n" +
+		"attachment body\r\n" +
+		"--b1--\r\n"
+
+	msg, err := Parse(strings.NewReader(raw))
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+
+	if !strings.Contains(msg.Text, "hello there") {
+		t.Fatalf("got Text %q, want it to contain %q", msg.Text, "hello there")
+	}
+	if len(msg.Attachments) != 1 {
+		t.Fatalf("got %d attachments, want 1", len(msg.Attachments))
+	}
+	if msg.Attachments[0].Filename != "note.txt" {
+		t.Fatalf("got filename %q, want %q", msg.Attachments[0].Filename, "note.txt")
+	}
+	if string(msg.Attachments[0].Bytes) != "attachment body" {
+		t.Fatalf("got body %q, want %q", msg.Attachments[0].Bytes, "attachment body")
+	}
+}
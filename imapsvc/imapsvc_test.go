@@ -0,0 +1,148 @@
+package imapsvc
+
+import (
+	"context"
+	"io"
+	"reflect"
+	"testing"
+
+	"github.com/emersion/go-imap"
+
+	"github.com/Kuzuru/IMAP/mail"
+)
+
+// fakeStore is a MailStore backed by an in-memory mailbox, demonstrating
+// that the fetch path can be exercised without a live IMAP server.
+type fakeStore struct {
+	mails []mail.Info
+}
+
+func (f *fakeStore) Connect(host, port string, opts ConnectOptions) error { return nil }
+func (f *fakeStore) Disconnect() error                                    { return nil }
+func (f *fakeStore) Folders() ([]string, error)                           { return []string{"INBOX"}, nil }
+
+func (f *fakeStore) Select(mailbox string) (*imap.MailboxStatus, error) {
+	return &imap.MailboxStatus{Name: mailbox}, nil
+}
+
+func (f *fakeStore) Search(criteria *imap.SearchCriteria) ([]uint32, error) {
+	var uids []uint32
+	for _, m := range f.mails {
+		uids = append(uids, m.UID)
+	}
+	return uids, nil
+}
+
+func (f *fakeStore) Fetch(uidSet *imap.SeqSet, items []imap.FetchItem) ([]mail.Info, error) {
+	var out []mail.Info
+	for _, m := range f.mails {
+		if uidSet.Contains(m.UID) {
+			out = append(out, m)
+		}
+	}
+	return out, nil
+}
+
+func (f *fakeStore) FetchBody(uidSet *imap.SeqSet) (map[uint32]io.Reader, error) {
+	return nil, nil
+}
+
+func (f *fakeStore) Watch(ctx context.Context, onUpdate func()) error {
+	<-ctx.Done()
+	return ctx.Err()
+}
+
+// TestFetchByUidSet exercises the same Select-then-Fetch sequence the CLI
+// runs for a plain (no search, no --watch) fetch, against a fake MailStore.
+func TestFetchByUidSet(t *testing.T) {
+	var store MailStore = &fakeStore{
+		mails: []mail.Info{
+			{UID: 1, Subject: "first"},
+			{UID: 2, Subject: "second"},
+			{UID: 3, Subject: "third"},
+		},
+	}
+
+	if _, err := store.Select("INBOX"); err != nil {
+		t.Fatalf("Select: %v", err)
+	}
+
+	uidSet := new(imap.SeqSet)
+	uidSet.AddRange(2, 0)
+
+	mails, err := store.Fetch(uidSet, DefaultFetchItems)
+	if err != nil {
+		t.Fatalf("Fetch: %v", err)
+	}
+
+	want := []string{"second", "third"}
+	var got []string
+	for _, m := range mails {
+		got = append(got, m.Subject)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got subjects %v, want %v", got, want)
+	}
+}
+
+func TestAttachments(t *testing.T) {
+	bs := &imap.BodyStructure{
+		MIMEType: "multipart",
+		Parts: []*imap.BodyStructure{
+			{MIMEType: "text", MIMESubType: "plain"},
+			{
+				MIMEType:    "application",
+				MIMESubType: "pdf",
+				Disposition: "attachment",
+				Params:      map[string]string{"filename": "invoice.pdf"},
+				Size:        1234,
+			},
+			{
+				MIMEType:    "image",
+				MIMESubType: "png",
+				Disposition: "attachment",
+				Params:      map[string]string{"name": "logo.png"},
+				Size:        56,
+			},
+		},
+	}
+
+	got := Attachments(bs)
+	want := []mail.Attachment{
+		{Filename: "invoice.pdf", Size: 1234},
+		{Filename: "logo.png", Size: 56},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Fatalf("got %+v, want %+v", got, want)
+	}
+}
+
+// TestInfoFromMessageBccOnly guards against indexing an empty Envelope.To,
+// which is ordinary for BCC-only delivery rather than malformed mail.
+func TestInfoFromMessageBccOnly(t *testing.T) {
+	msg := &imap.Message{
+		Envelope: &imap.Envelope{
+			From:    []*imap.Address{{PersonalName: "Sender", MailboxName: "sender", HostName: "example.com"}},
+			To:      nil,
+			Subject: "bcc only",
+		},
+		BodyStructure: &imap.BodyStructure{MIMEType: "text", MIMESubType: "plain"},
+	}
+
+	info := infoFromMessage(msg)
+	if info.From != "sender@example.com" {
+		t.Fatalf("got From %q, want %q", info.From, "sender@example.com")
+	}
+	if info.To != "" {
+		t.Fatalf("got To %q, want empty", info.To)
+	}
+}
+
+func TestAttachmentsNoneFound(t *testing.T) {
+	bs := &imap.BodyStructure{MIMEType: "text", MIMESubType: "plain"}
+
+	got := Attachments(bs)
+	if len(got) != 0 {
+		t.Fatalf("got %+v, want no attachments", got)
+	}
+}
@@ -0,0 +1,22 @@
+// Package mail contains the domain types shared between the fetch pipeline
+// and the CLI output layer, independent of how a message was retrieved or
+// how it will be rendered.
+package mail
+
+// Info describes a single fetched message.
+type Info struct {
+	From, To, Subject, Date string
+	Size, Attachments       int
+	AttachmentNames         []string
+	MessageID               string
+	InReplyTo               string
+	UID                     uint32
+	Flags                   []string
+}
+
+// Attachment describes a single attachment found while walking a message's
+// body structure.
+type Attachment struct {
+	Filename string
+	Size     int
+}
@@ -0,0 +1,196 @@
+// Package render formats fetched mail for display, selecting output
+// format and columns independently.
+package render
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/Kuzuru/IMAP/mail"
+)
+
+// Format selects how a Writer renders mail.Info values.
+type Format string
+
+const (
+	FormatText   Format = "text"
+	FormatJSON   Format = "json"
+	FormatNDJSON Format = "ndjson"
+	FormatCSV    Format = "csv"
+)
+
+// DefaultFields is the column order used when no --fields selector is given.
+var DefaultFields = []string{"from", "to", "subject", "date", "size", "attachments", "attachment_names"}
+
+// Writer renders a batch of mail.Info values.
+type Writer interface {
+	Write(mails []mail.Info) error
+}
+
+// NewWriter returns a Writer for the given format, rendering the given
+// fields (DefaultFields if empty) in that order. Valid field names are
+// from, to, subject, date, size, attachments, attachment_names,
+// message_id, in_reply_to, uid and flags.
+func NewWriter(w io.Writer, format Format, fields []string) (Writer, error) {
+	if len(fields) == 0 {
+		fields = DefaultFields
+	}
+
+	switch format {
+	case "", FormatText:
+		return &textWriter{w: w, fields: fields}, nil
+	case FormatCSV:
+		return &csvWriter{w: w, fields: fields}, nil
+	case FormatJSON:
+		return &jsonWriter{w: w, fields: fields, ndjson: false}, nil
+	case FormatNDJSON:
+		return &jsonWriter{w: w, fields: fields, ndjson: true}, nil
+	default:
+		return nil, fmt.Errorf("unknown output format %q", format)
+	}
+}
+
+type textWriter struct {
+	w      io.Writer
+	fields []string
+}
+
+func (tw *textWriter) Write(mails []mail.Info) error {
+	if _, err := fmt.Fprintln(tw.w, strings.Join(headers(tw.fields), "\t")); err != nil {
+		return err
+	}
+	for _, m := range mails {
+		if _, err := fmt.Fprintln(tw.w, strings.Join(row(m, tw.fields), "\t")); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+type csvWriter struct {
+	w      io.Writer
+	fields []string
+}
+
+func (cw *csvWriter) Write(mails []mail.Info) error {
+	out := csv.NewWriter(cw.w)
+	if err := out.Write(headers(cw.fields)); err != nil {
+		return err
+	}
+	for _, m := range mails {
+		if err := out.Write(row(m, cw.fields)); err != nil {
+			return err
+		}
+	}
+	out.Flush()
+	return out.Error()
+}
+
+type jsonWriter struct {
+	w      io.Writer
+	fields []string
+	ndjson bool
+}
+
+func (jw *jsonWriter) Write(mails []mail.Info) error {
+	records := make([]map[string]string, len(mails))
+	for i, m := range mails {
+		rec := make(map[string]string, len(jw.fields))
+		for _, field := range jw.fields {
+			rec[field] = fieldValue(m, field)
+		}
+		records[i] = rec
+	}
+
+	if !jw.ndjson {
+		enc := json.NewEncoder(jw.w)
+		enc.SetIndent("", "  ")
+		return enc.Encode(records)
+	}
+
+	enc := json.NewEncoder(jw.w)
+	for _, rec := range records {
+		if err := enc.Encode(rec); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func headers(fields []string) []string {
+	out := make([]string, len(fields))
+	for i, field := range fields {
+		out[i] = fieldHeader(field)
+	}
+	return out
+}
+
+func row(m mail.Info, fields []string) []string {
+	out := make([]string, len(fields))
+	for i, field := range fields {
+		out[i] = fieldValue(m, field)
+	}
+	return out
+}
+
+func fieldHeader(field string) string {
+	switch field {
+	case "from":
+		return "From"
+	case "to":
+		return "To"
+	case "subject":
+		return "Subject"
+	case "date":
+		return "Date"
+	case "size":
+		return "Size"
+	case "attachments":
+		return "Attachments"
+	case "attachment_names":
+		return "Attachment Names"
+	case "message_id":
+		return "Message-Id"
+	case "in_reply_to":
+		return "In-Reply-To"
+	case "uid":
+		return "UID"
+	case "flags":
+		return "Flags"
+	default:
+		return field
+	}
+}
+
+func fieldValue(m mail.Info, field string) string {
+	switch field {
+	case "from":
+		return m.From
+	case "to":
+		return m.To
+	case "subject":
+		return m.Subject
+	case "date":
+		return m.Date
+	case "size":
+		return strconv.Itoa(m.Size)
+	case "attachments":
+		return strconv.Itoa(m.Attachments)
+	case "attachment_names":
+		return strings.Join(m.AttachmentNames, ", ")
+	case "message_id":
+		return m.MessageID
+	case "in_reply_to":
+		return m.InReplyTo
+	case "uid":
+		return strconv.FormatUint(uint64(m.UID), 10)
+	case "flags":
+		return strings.Join(m.Flags, ", ")
+	default:
+		return ""
+	}
+}
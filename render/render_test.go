@@ -0,0 +1,120 @@
+package render
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/Kuzuru/IMAP/mail"
+)
+
+var sample = []mail.Info{
+	{From: "a@example.com", To: "b@example.com", Subject: "hi", UID: 1, Attachments: 0},
+	{From: "c@example.com", To: "d@example.com", Subject: "re: hi", UID: 2, Attachments: 1, AttachmentNames: []string{"x.txt"}},
+}
+
+func TestTextWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatText, []string{"from", "subject"})
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.Write(sample); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 3 {
+		t.Fatalf("got %d lines, want 3 (header + 2 rows)", len(lines))
+	}
+	if lines[0] != "From\tSubject" {
+		t.Fatalf("got header %q, want %q", lines[0], "From\tSubject")
+	}
+	if lines[1] != "a@example.com\thi" {
+		t.Fatalf("got row %q, want %q", lines[1], "a@example.com\thi")
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatCSV, []string{"uid", "subject"})
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.Write(sample); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	want := "UID,Subject\n1,hi\n2,re: hi\n"
+	if buf.String() != want {
+		t.Fatalf("got %q, want %q", buf.String(), want)
+	}
+}
+
+func TestJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatJSON, []string{"from", "attachment_names"})
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.Write(sample); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	var records []map[string]string
+	if err := json.Unmarshal(buf.Bytes(), &records); err != nil {
+		t.Fatalf("Unmarshal: %v", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("got %d records, want 2", len(records))
+	}
+	if records[1]["attachment_names"] != "x.txt" {
+		t.Fatalf("got attachment_names %q, want %q", records[1]["attachment_names"], "x.txt")
+	}
+}
+
+func TestNDJSONWriter(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatNDJSON, []string{"uid"})
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.Write(sample); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	lines := strings.Split(strings.TrimRight(buf.String(), "\n"), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("got %d lines, want 2 (one JSON object per message)", len(lines))
+	}
+	var rec map[string]string
+	if err := json.Unmarshal([]byte(lines[0]), &rec); err != nil {
+		t.Fatalf("Unmarshal line 1: %v", err)
+	}
+	if rec["uid"] != "1" {
+		t.Fatalf("got uid %q, want %q", rec["uid"], "1")
+	}
+}
+
+func TestNewWriterDefaultFields(t *testing.T) {
+	var buf bytes.Buffer
+	w, err := NewWriter(&buf, FormatText, nil)
+	if err != nil {
+		t.Fatalf("NewWriter: %v", err)
+	}
+	if err := w.Write(sample[:1]); err != nil {
+		t.Fatalf("Write: %v", err)
+	}
+
+	header := strings.SplitN(buf.String(), "\n", 2)[0]
+	if header != strings.Join(headers(DefaultFields), "\t") {
+		t.Fatalf("got header %q, want default fields %q", header, DefaultFields)
+	}
+}
+
+func TestNewWriterUnknownFormat(t *testing.T) {
+	if _, err := NewWriter(&bytes.Buffer{}, Format("bogus"), nil); err == nil {
+		t.Fatal("want error for unknown format, got nil")
+	}
+}
@@ -0,0 +1,156 @@
+// Package search translates a small expression language into an
+// imap.SearchCriteria, e.g.
+//
+//	from:alice@example.com since:2024-01-01 subject:"invoice" unseen larger:1M
+package search
+
+import (
+	"fmt"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/emersion/go-imap"
+)
+
+const dateLayout = "2006-01-02"
+
+// Parse turns a search expression into imap.SearchCriteria. Terms are
+// whitespace-separated; a term is either a bare flag (unseen, seen,
+// flagged) or a key:value pair. Values containing spaces must be quoted,
+// e.g. subject:"invoice paid".
+func Parse(expr string) (*imap.SearchCriteria, error) {
+	tokens, err := tokenize(expr)
+	if err != nil {
+		return nil, err
+	}
+
+	criteria := &imap.SearchCriteria{}
+	for _, tok := range tokens {
+		key, value, hasValue := strings.Cut(tok, ":")
+		if !hasValue {
+			if err := applyFlag(criteria, tok); err != nil {
+				return nil, err
+			}
+			continue
+		}
+		if err := applyField(criteria, key, value); err != nil {
+			return nil, err
+		}
+	}
+
+	return criteria, nil
+}
+
+func applyFlag(c *imap.SearchCriteria, tok string) error {
+	switch tok {
+	case "unseen":
+		c.WithoutFlags = append(c.WithoutFlags, imap.SeenFlag)
+	case "seen":
+		c.WithFlags = append(c.WithFlags, imap.SeenFlag)
+	case "flagged":
+		c.WithFlags = append(c.WithFlags, imap.FlaggedFlag)
+	default:
+		return fmt.Errorf("unknown search term %q", tok)
+	}
+	return nil
+}
+
+func applyField(c *imap.SearchCriteria, key, value string) error {
+	switch key {
+	case "from":
+		addHeader(c, "From", value)
+	case "to":
+		addHeader(c, "To", value)
+	case "subject":
+		addHeader(c, "Subject", value)
+	case "since":
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return fmt.Errorf("since: %w", err)
+		}
+		c.Since = t
+	case "before":
+		t, err := time.Parse(dateLayout, value)
+		if err != nil {
+			return fmt.Errorf("before: %w", err)
+		}
+		c.Before = t
+	case "larger":
+		n, err := parseSize(value)
+		if err != nil {
+			return fmt.Errorf("larger: %w", err)
+		}
+		c.Larger = n
+	case "smaller":
+		n, err := parseSize(value)
+		if err != nil {
+			return fmt.Errorf("smaller: %w", err)
+		}
+		c.Smaller = n
+	default:
+		return fmt.Errorf("unknown search field %q", key)
+	}
+	return nil
+}
+
+func addHeader(c *imap.SearchCriteria, header, value string) {
+	if c.Header == nil {
+		c.Header = textproto.MIMEHeader{}
+	}
+	c.Header.Add(header, value)
+}
+
+// parseSize parses byte sizes such as "1M", "512K" or "1G", in addition to
+// a bare byte count.
+func parseSize(s string) (uint32, error) {
+	mult := uint64(1)
+	switch {
+	case strings.HasSuffix(s, "K"), strings.HasSuffix(s, "k"):
+		mult, s = 1024, s[:len(s)-1]
+	case strings.HasSuffix(s, "M"), strings.HasSuffix(s, "m"):
+		mult, s = 1024*1024, s[:len(s)-1]
+	case strings.HasSuffix(s, "G"), strings.HasSuffix(s, "g"):
+		mult, s = 1024*1024*1024, s[:len(s)-1]
+	}
+
+	n, err := strconv.ParseUint(s, 10, 64)
+	if err != nil {
+		return 0, err
+	}
+	return uint32(n * mult), nil
+}
+
+// tokenize splits expr on whitespace, honoring double-quoted values so
+// subject:"invoice paid" stays a single token.
+func tokenize(expr string) ([]string, error) {
+	var tokens []string
+	var cur strings.Builder
+	inQuotes := false
+
+	flush := func() {
+		if cur.Len() > 0 {
+			tokens = append(tokens, cur.String())
+			cur.Reset()
+		}
+	}
+
+	for _, r := range expr {
+		switch {
+		case r == '"':
+			inQuotes = !inQuotes
+		case unicode.IsSpace(r) && !inQuotes:
+			flush()
+		default:
+			cur.WriteRune(r)
+		}
+	}
+	if inQuotes {
+		return nil, fmt.Errorf("unterminated quote in search expression")
+	}
+	flush()
+
+	return tokens, nil
+}
@@ -0,0 +1,75 @@
+package search
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseFlags(t *testing.T) {
+	c, err := Parse("unseen flagged")
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if len(c.WithoutFlags) != 1 || c.WithoutFlags[0] != "\\Seen" {
+		t.Fatalf("got WithoutFlags %v, want [\\Seen]", c.WithoutFlags)
+	}
+	if len(c.WithFlags) != 1 || c.WithFlags[0] != "\\Flagged" {
+		t.Fatalf("got WithFlags %v, want [\\Flagged]", c.WithFlags)
+	}
+}
+
+func TestParseFields(t *testing.T) {
+	c, err := Parse(`from:alice@example.com subject:"invoice paid" since:2024-01-01 larger:1M`)
+	if err != nil {
+		t.Fatalf("Parse: %v", err)
+	}
+	if got := c.Header.Get("From"); got != "alice@example.com" {
+		t.Fatalf("got From header %q, want %q", got, "alice@example.com")
+	}
+	if got := c.Header.Get("Subject"); got != "invoice paid" {
+		t.Fatalf("got Subject header %q, want %q", got, "invoice paid")
+	}
+	if want := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC); !c.Since.Equal(want) {
+		t.Fatalf("got Since %v, want %v", c.Since, want)
+	}
+	if c.Larger != 1024*1024 {
+		t.Fatalf("got Larger %d, want %d", c.Larger, 1024*1024)
+	}
+}
+
+func TestParseUnknownTerms(t *testing.T) {
+	if _, err := Parse("bogus"); err == nil {
+		t.Fatal("want error for unknown flag, got nil")
+	}
+	if _, err := Parse("wat:1"); err == nil {
+		t.Fatal("want error for unknown field, got nil")
+	}
+}
+
+func TestParseUnterminatedQuote(t *testing.T) {
+	if _, err := Parse(`subject:"invoice`); err == nil {
+		t.Fatal("want error for unterminated quote, got nil")
+	}
+}
+
+func TestParseSize(t *testing.T) {
+	cases := map[string]uint32{
+		"512":  512,
+		"512K": 512 * 1024,
+		"1m":   1024 * 1024,
+		"2G":   2 * 1024 * 1024 * 1024,
+	}
+	for in, want := range cases {
+		got, err := parseSize(in)
+		if err != nil {
+			t.Fatalf("parseSize(%q): %v", in, err)
+		}
+		if got != want {
+			t.Fatalf("parseSize(%q) = %d, want %d", in, got, want)
+		}
+	}
+
+	if _, err := parseSize("abc"); err == nil {
+		t.Fatal("want error for non-numeric size, got nil")
+	}
+}